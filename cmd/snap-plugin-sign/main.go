@@ -0,0 +1,45 @@
+// snap-plugin-sign signs a plugin binary with an RSA private key, writing
+// the signature to "<path>.sig" so pluginManager.LoadPlugin can verify it
+// against a trusted key at load time.
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"io/ioutil"
+	"log"
+
+	"github.com/intelsdilabs/pulse/control"
+)
+
+func main() {
+	keyPath := flag.String("key", "", "path to a PEM-encoded RSA private key")
+	flag.Parse()
+
+	if *keyPath == "" || flag.NArg() != 1 {
+		log.Fatal("usage: snap-plugin-sign -key <private-key.pem> <plugin-binary>")
+	}
+	pluginPath := flag.Arg(0)
+
+	keyBytes, err := ioutil.ReadFile(*keyPath)
+	if err != nil {
+		log.Fatalf("reading private key: %v", err)
+	}
+
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		log.Fatalf("no PEM block found in %s", *keyPath)
+	}
+
+	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		log.Fatalf("parsing private key: %v", err)
+	}
+
+	if err := control.SignPluginFile(pluginPath, priv); err != nil {
+		log.Fatalf("signing %s: %v", pluginPath, err)
+	}
+
+	log.Printf("wrote %s.sig", pluginPath)
+}
@@ -0,0 +1,321 @@
+package control
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// pluginNamePrefix is the naming convention Discoverer auto-loads: any
+// executable in a watched directory whose basename starts with this is
+// assumed to be a plugin.
+const pluginNamePrefix = "snap-plugin-"
+
+// DiscoveryEventType identifies what happened to a plugin binary that
+// Discoverer noticed.
+type DiscoveryEventType string
+
+const (
+	Loaded     DiscoveryEventType = "loaded"
+	Unloaded   DiscoveryEventType = "unloaded"
+	LoadFailed DiscoveryEventType = "load_failed"
+)
+
+// DiscoveryEvent is published on Discoverer.Events() whenever a watched
+// directory's contents change in a way that affects the catalog.
+type DiscoveryEvent struct {
+	Type DiscoveryEventType
+	Path string
+	Err  error
+}
+
+// quarantineEntry tracks repeated load failures for a single path so
+// Discoverer can back off instead of retrying a broken binary on every
+// filesystem event.
+type quarantineEntry struct {
+	failures int
+	until    time.Time
+}
+
+const (
+	maxDiscoveryBackoff  = 5 * time.Minute
+	discoveryBaseBackoff = 10 * time.Second
+)
+
+// Discoverer watches one or more directories for plugin binaries and
+// auto-loads/-unloads them into a pluginManager's catalog as they appear
+// and disappear on disk.
+type Discoverer struct {
+	manager *pluginManager
+
+	dirsMu sync.Mutex
+	dirs   map[string]bool
+
+	watcher *fsnotify.Watcher
+	events  chan DiscoveryEvent
+
+	quarantineMu sync.Mutex
+	quarantine   map[string]*quarantineEntry
+
+	// debounce absorbs the create+write(+rename) bursts that atomic
+	// "write to temp file then rename into place" deploys generate, so a
+	// single deploy triggers a single load attempt.
+	debounce time.Duration
+
+	pendingMu sync.Mutex
+	pending   map[string]*time.Timer
+
+	stop chan struct{}
+}
+
+// newDiscoverer creates a Discoverer that auto-loads plugins into manager.
+// Callers must call AddPluginDir for every directory that should be
+// watched, and Close when finished.
+func newDiscoverer(manager *pluginManager) (*Discoverer, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Discoverer{
+		manager:    manager,
+		dirs:       make(map[string]bool),
+		watcher:    watcher,
+		events:     make(chan DiscoveryEvent, 32),
+		quarantine: make(map[string]*quarantineEntry),
+		debounce:   500 * time.Millisecond,
+		pending:    make(map[string]*time.Timer),
+		stop:       make(chan struct{}),
+	}
+
+	go d.run()
+	return d, nil
+}
+
+// Events returns the channel Discoverer publishes Loaded, Unloaded, and
+// LoadFailed events to. Callers should drain it continuously -- a full
+// buffer causes events to be dropped rather than blocking discovery.
+func (d *Discoverer) Events() <-chan DiscoveryEvent {
+	return d.events
+}
+
+// AddPluginDir starts watching dir for plugin binaries matching the
+// snap-plugin-* naming convention, auto-loading any that are already
+// present.
+func (d *Discoverer) AddPluginDir(dir string) error {
+	d.dirsMu.Lock()
+	if d.dirs[dir] {
+		d.dirsMu.Unlock()
+		return nil
+	}
+	d.dirs[dir] = true
+	d.dirsMu.Unlock()
+
+	if err := d.watcher.Add(dir); err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		d.maybeLoad(filepath.Join(dir, entry.Name()))
+	}
+
+	return nil
+}
+
+// RemovePluginDir stops watching dir. Plugins already loaded from it are
+// left running; unload them explicitly through the pluginManager if
+// that's also desired.
+func (d *Discoverer) RemovePluginDir(dir string) error {
+	d.dirsMu.Lock()
+	delete(d.dirs, dir)
+	d.dirsMu.Unlock()
+
+	return d.watcher.Remove(dir)
+}
+
+// Close stops the watch loop and releases the underlying fsnotify watcher.
+func (d *Discoverer) Close() error {
+	close(d.stop)
+	return d.watcher.Close()
+}
+
+func (d *Discoverer) run() {
+	for {
+		select {
+		case ev, ok := <-d.watcher.Events:
+			if !ok {
+				return
+			}
+			d.handleEvent(ev)
+		case err, ok := <-d.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("discoverer: watch error:", err)
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+func (d *Discoverer) handleEvent(ev fsnotify.Event) {
+	if !isPluginBinary(ev.Name) {
+		return
+	}
+
+	switch {
+	case ev.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) != 0:
+		d.debouncedLoad(ev.Name)
+	case ev.Op&fsnotify.Remove != 0:
+		d.unload(ev.Name)
+	}
+}
+
+// debouncedLoad schedules a load attempt for path after d.debounce,
+// restarting the timer if another event for the same path arrives first.
+func (d *Discoverer) debouncedLoad(path string) {
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+
+	if t, exists := d.pending[path]; exists {
+		t.Stop()
+	}
+	d.pending[path] = time.AfterFunc(d.debounce, func() {
+		d.pendingMu.Lock()
+		delete(d.pending, path)
+		d.pendingMu.Unlock()
+		d.maybeLoad(path)
+	})
+}
+
+func isPluginBinary(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), pluginNamePrefix)
+}
+
+func (d *Discoverer) quarantined(path string) bool {
+	d.quarantineMu.Lock()
+	defer d.quarantineMu.Unlock()
+
+	q, ok := d.quarantine[path]
+	return ok && time.Now().Before(q.until)
+}
+
+// recordFailure backs off exponentially (capped at maxDiscoveryBackoff) on
+// each repeated failure of the same path, so a consistently broken binary
+// stops being retried on every debounce tick.
+func (d *Discoverer) recordFailure(path string) {
+	d.quarantineMu.Lock()
+	defer d.quarantineMu.Unlock()
+
+	q, ok := d.quarantine[path]
+	if !ok {
+		q = &quarantineEntry{}
+		d.quarantine[path] = q
+	}
+	q.failures++
+
+	backoff := discoveryBaseBackoff
+	for i := 1; i < q.failures && backoff < maxDiscoveryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxDiscoveryBackoff {
+		backoff = maxDiscoveryBackoff
+	}
+	q.until = time.Now().Add(backoff)
+}
+
+func (d *Discoverer) clearFailures(path string) {
+	d.quarantineMu.Lock()
+	delete(d.quarantine, path)
+	d.quarantineMu.Unlock()
+}
+
+func (d *Discoverer) maybeLoad(path string) {
+	if !isPluginBinary(path) || d.quarantined(path) {
+		return
+	}
+
+	// only executables are plugins: this skips e.g. a "<path>.sig" sidecar
+	// that shares the prefix. A stat failure falls through so LoadPlugin
+	// reports the real error.
+	if info, err := os.Stat(path); err == nil && (!info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0) {
+		return
+	}
+
+	// an atomic-rename deploy replaces the binary at path without ever
+	// producing a Remove event for the destination, so a settled, already-
+	// loaded entry whose file has since changed must be explicitly
+	// unloaded first -- otherwise LoadPlugin's join-in-flight logic just
+	// hands back the stale entry instead of picking up the new content. A
+	// LoadingState entry is left alone: LoadPlugin below will join it.
+	if lp, found := d.manager.LoadedPlugins.FindByPath(path); found {
+		state, modTime := lp.snapshot()
+		if state != LoadingState && d.changed(path, modTime) {
+			// a concurrent unload of this same stale entry (e.g. from
+			// another debounced event for the same replace) isn't an
+			// error here -- either way the stale entry is gone, so fall
+			// through and load the replacement binary
+			if err := d.manager.unloadPlugin(lp.Meta.Name, lp.Type.String(), lp.Meta.Version, true); err != nil {
+				if _, stillThere := d.manager.LoadedPlugins.FindByPath(path); stillThere {
+					d.publish(DiscoveryEvent{Type: LoadFailed, Path: path, Err: err})
+					return
+				}
+			}
+		}
+	}
+
+	if err := d.manager.LoadPlugin(path); err != nil {
+		d.recordFailure(path)
+		d.publish(DiscoveryEvent{Type: LoadFailed, Path: path, Err: err})
+		return
+	}
+
+	d.clearFailures(path)
+	d.publish(DiscoveryEvent{Type: Loaded, Path: path})
+}
+
+// changed reports whether the file at path has a newer mtime than
+// loadedModTime (the mtime recorded when the currently-cataloged entry was
+// loaded). Used to tell "this path is already settled and the binary was
+// replaced" apart from "nothing changed" for a Create/Write/Rename event
+// on an already-loaded path. Stat failures are treated as unchanged --
+// maybeLoad's own LoadPlugin call will surface the real error.
+func (d *Discoverer) changed(path string, loadedModTime time.Time) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return info.ModTime().After(loadedModTime)
+}
+
+func (d *Discoverer) unload(path string) {
+	lp, found := d.manager.LoadedPlugins.FindByPath(path)
+	if !found {
+		return
+	}
+
+	if err := d.manager.unloadPlugin(lp.Meta.Name, lp.Type.String(), lp.Meta.Version, true); err != nil {
+		d.publish(DiscoveryEvent{Type: LoadFailed, Path: path, Err: err})
+		return
+	}
+
+	d.publish(DiscoveryEvent{Type: Unloaded, Path: path})
+}
+
+func (d *Discoverer) publish(ev DiscoveryEvent) {
+	select {
+	case d.events <- ev:
+	default:
+		log.Printf("discoverer: event channel full, dropping %s event for %s", ev.Type, ev.Path)
+	}
+}
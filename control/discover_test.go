@@ -0,0 +1,90 @@
+package control
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIsPluginBinary(t *testing.T) {
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{"/usr/lib/snap/snap-plugin-collector-foo", true},
+		{"/usr/lib/snap/snap-plugin-publisher-bar", true},
+		{"/usr/lib/snap/README.md", false},
+		{"/usr/lib/snap/not-a-plugin", false},
+	}
+	for _, c := range cases {
+		if got := isPluginBinary(c.path); got != c.want {
+			t.Errorf("isPluginBinary(%q) = %v, want %v", c.path, got, c.want)
+		}
+	}
+}
+
+// TestQuarantineBacksOffAndClears covers chunk0-6: a repeatedly failing
+// binary should back off for longer on each successive failure, and an
+// explicit clear should lift the quarantine immediately.
+func TestQuarantineBacksOffAndClears(t *testing.T) {
+	d := &Discoverer{quarantine: make(map[string]*quarantineEntry)}
+	path := "/tmp/snap-plugin-bad"
+
+	if d.quarantined(path) {
+		t.Fatal("a path with no recorded failures should not be quarantined")
+	}
+
+	d.recordFailure(path)
+	if !d.quarantined(path) {
+		t.Fatal("expected the path to be quarantined immediately after a recorded failure")
+	}
+
+	d.quarantineMu.Lock()
+	first := d.quarantine[path].until
+	d.quarantineMu.Unlock()
+
+	d.recordFailure(path)
+	d.quarantineMu.Lock()
+	second := d.quarantine[path].until
+	d.quarantineMu.Unlock()
+
+	if !second.After(first) {
+		t.Error("expected the backoff window to grow after a repeated failure")
+	}
+
+	d.clearFailures(path)
+	if d.quarantined(path) {
+		t.Error("expected clearFailures to lift the quarantine")
+	}
+}
+
+// TestChangedDetectsReplacedBinary covers chunk0-6: an atomic-rename deploy
+// never produces a Remove event for the path it replaces, so maybeLoad
+// leans on changed to tell a stale, already-loaded entry apart from one
+// whose file genuinely hasn't moved since it was loaded.
+func TestChangedDetectsReplacedBinary(t *testing.T) {
+	f, err := ioutil.TempFile("", "snap-plugin-changed-test")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	path := f.Name()
+	f.Close()
+	defer os.Remove(path)
+
+	d := &Discoverer{}
+	loadedModTime := time.Now()
+
+	if d.changed(path, loadedModTime) {
+		t.Error("expected an untouched file to not be reported as changed")
+	}
+
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	if !d.changed(path, loadedModTime) {
+		t.Error("expected a file with a newer mtime than loadedModTime to be reported as changed")
+	}
+}
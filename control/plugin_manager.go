@@ -7,6 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -28,6 +30,10 @@ type loadedPlugins struct {
 	table       *[]*loadedPlugin
 	mutex       *sync.Mutex
 	currentIter int
+
+	// pinned records the version that consumers resolve to by default for
+	// a given (name, type) pair. Absence of an entry means "latest loaded".
+	pinned map[string]int
 }
 
 func newLoadedPlugins() *loadedPlugins {
@@ -36,6 +42,7 @@ func newLoadedPlugins() *loadedPlugins {
 		table:       &t,
 		mutex:       new(sync.Mutex),
 		currentIter: 0,
+		pinned:      make(map[string]int),
 	}
 }
 
@@ -60,21 +67,145 @@ func (l *loadedPlugins) Append(lp *loadedPlugin) error {
 	return nil
 }
 
+// pinKey returns the map key used to track the pinned version of a
+// (name, type) pair, independent of version.
+func pinKey(name string, typ plugin.PluginType) string {
+	return name + "::" + typ.String()
+}
+
+// Pin marks version as the default version resolved for (name, type).
+// The version must already be present in the table.
+func (l *loadedPlugins) Pin(name string, typ plugin.PluginType, version int) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, pl := range *l.table {
+		if pl.Meta.Name == name && pl.Type == typ && pl.Meta.Version == version {
+			l.pinned[pinKey(name, typ)] = version
+			return nil
+		}
+	}
+	return errors.New("cannot pin " + name + " -- no loaded plugin at version " + strconv.Itoa(version))
+}
+
+// Unpin removes the pinned version for (name, type), if any.
+func (l *loadedPlugins) Unpin(name string, typ plugin.PluginType) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.pinned, pinKey(name, typ))
+}
+
+// PinnedVersion returns the version pinned for (name, type) and whether a
+// pin exists.
+func (l *loadedPlugins) PinnedVersion(name string, typ plugin.PluginType) (int, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.pinnedVersionLocked(name, typ)
+}
+
+// pinnedVersionLocked is PinnedVersion for callers that already hold the
+// mutex (e.g. while iterating with Next/Item under an explicit Lock).
+func (l *loadedPlugins) pinnedVersionLocked(name string, typ plugin.PluginType) (int, bool) {
+	v, ok := l.pinned[pinKey(name, typ)]
+	return v, ok
+}
+
+// Versions returns the sorted list of versions loaded for (name, type).
+func (l *loadedPlugins) Versions(name string, typ plugin.PluginType) []int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	versions := make([]int, 0)
+	for _, pl := range *l.table {
+		if pl.Meta.Name == name && pl.Type == typ {
+			versions = append(versions, pl.Meta.Version)
+		}
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// ByNameVersion returns the table index and entry matching (name, type,
+// version), or found == false if no such plugin is loaded.
+func (l *loadedPlugins) ByNameVersion(name string, typ plugin.PluginType, version int) (index int, lp *loadedPlugin, found bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i, pl := range *l.table {
+		if pl.Meta.Name == name && pl.Type == typ && pl.Meta.Version == version {
+			return i, pl, true
+		}
+	}
+	return 0, nil, false
+}
+
 // returns a copy of the table
 func (l *loadedPlugins) Table() []*loadedPlugin {
 	return *l.table
 }
 
-// used to transactionally retrieve a loadedPlugin pointer from the table
+// used to transactionally retrieve a loadedPlugin pointer from the table.
+// If the plugin is still being loaded, Get blocks until it either finishes
+// loading or is removed from the table after a failed load.
 func (l *loadedPlugins) Get(index int) (*loadedPlugin, error) {
 	l.Lock()
-	defer l.Unlock()
 
 	if index > len(*l.table)-1 {
+		l.Unlock()
 		return nil, errors.New("index out of range")
 	}
+	lp := (*l.table)[index]
+	l.Unlock()
 
-	return (*l.table)[index], nil
+	if err := lp.Wait(); err != nil {
+		return nil, err
+	}
+
+	return lp, nil
+}
+
+// FindByPath returns the entry loading or loaded from path, if any. Used by
+// Discoverer to look up the cataloged entry for a path it got a filesystem
+// event for.
+func (l *loadedPlugins) FindByPath(path string) (*loadedPlugin, bool) {
+	l.mutex.Lock()
+	var lp *loadedPlugin
+	for _, pl := range *l.table {
+		if pl.Path == path {
+			lp = pl
+			break
+		}
+	}
+	l.mutex.Unlock()
+
+	return lp, lp != nil
+}
+
+// FindOrInsertLoading atomically looks up the entry for path and, if none
+// exists, inserts a new LoadingState placeholder for it -- all under a
+// single mutex acquisition. This is what makes loadPlugin's join-or-claim
+// decision race-free: a separate FindByPath followed by Append leaves a gap
+// (signature verification, process spawn) during which two concurrent
+// callers can both observe "not found" and append duplicate entries for
+// the same path.
+//
+// inserted reports which branch happened: true means the caller claimed a
+// fresh placeholder and owns driving it to markLoaded/markFailed; false
+// means lp is a pre-existing entry the caller should Wait() on.
+func (l *loadedPlugins) FindOrInsertLoading(path string) (lp *loadedPlugin, inserted bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, pl := range *l.table {
+		if pl.Path == path {
+			return pl, false
+		}
+	}
+
+	lp = newLoadedPlugin(path)
+	t := append(*l.table, lp)
+	l.table = &t
+	return lp, true
 }
 
 // used to lock the plugin table externally,
@@ -98,6 +229,8 @@ func (l *loadedPlugins) splice(index int) {
 }
 
 // splice unsafely
+//
+// Deprecated: use RemoveByKey, which finds and splices atomically.
 func (l *loadedPlugins) NonblockingSplice(index int) {
 	l.splice(index)
 }
@@ -113,6 +246,10 @@ func (l *loadedPlugins) Splice(index int) {
 
 // returns the item of a certain index in the table.
 // to be used when iterating over the table
+//
+// Deprecated: stores iteration state on the collection itself, which is
+// not reentrant and requires the caller to hold the table lock for the
+// duration of the loop. Use Range, Find, or FindByNameTypeName instead.
 func (l *loadedPlugins) Item() (int, *loadedPlugin) {
 	i := l.currentIter - 1
 	return i, (*l.table)[i]
@@ -120,6 +257,8 @@ func (l *loadedPlugins) Item() (int, *loadedPlugin) {
 
 // Returns true until the "end" of the table is reached.
 // used to iterate over the table:
+//
+// Deprecated: see Item.
 func (l *loadedPlugins) Next() bool {
 	l.currentIter++
 	if l.currentIter > len(*l.table) {
@@ -129,6 +268,118 @@ func (l *loadedPlugins) Next() bool {
 	return true
 }
 
+// Range calls fn for every entry in a point-in-time snapshot of the
+// table, in order, stopping early if fn returns false. Because it
+// iterates a copy taken under the lock rather than the live table, it is
+// reentrant and safe to call from multiple goroutines concurrently,
+// unlike Next/Item.
+func (l *loadedPlugins) Range(fn func(i int, lp *loadedPlugin) bool) {
+	l.mutex.Lock()
+	snapshot := make([]*loadedPlugin, len(*l.table))
+	copy(snapshot, *l.table)
+	l.mutex.Unlock()
+
+	for i, lp := range snapshot {
+		if !fn(i, lp) {
+			return
+		}
+	}
+}
+
+// Find returns the first entry for which pred returns true.
+func (l *loadedPlugins) Find(pred func(lp *loadedPlugin) bool) (*loadedPlugin, bool) {
+	var found *loadedPlugin
+	l.Range(func(i int, lp *loadedPlugin) bool {
+		if pred(lp) {
+			found = lp
+			return false
+		}
+		return true
+	})
+	return found, found != nil
+}
+
+// FindByNameTypeName returns the entry matching (name, version) whose
+// plugin type stringifies to typeName. Used by UnloadPlugin, whose
+// CatalogedPlugin argument carries a type name (via TypeName()) but not a
+// plugin.PluginType, so ByNameVersion isn't usable directly.
+func (l *loadedPlugins) FindByNameTypeName(name, typeName string, version int) (*loadedPlugin, bool) {
+	return l.Find(func(lp *loadedPlugin) bool {
+		return lp.Meta.Name == name && lp.Meta.Version == version && lp.Type.String() == typeName
+	})
+}
+
+// unpinIfRemovedLocked clears the pin for (name, typ) if it currently
+// points at version. Callers must hold l.mutex. Removal and un-pinning have
+// to happen under the same lock acquisition as the splice, or a concurrent
+// PinnedVersion/Pin call could observe a pin that points at a version no
+// longer in the table.
+func (l *loadedPlugins) unpinIfRemovedLocked(name string, typ plugin.PluginType, version int) {
+	key := pinKey(name, typ)
+	if pinned, ok := l.pinned[key]; ok && pinned == version {
+		delete(l.pinned, key)
+	}
+}
+
+// RemoveByKey atomically finds and removes the entry matching (name,
+// typeName, version), returning whether an entry was removed. name+version
+// alone is not a safe key: two different plugin types (e.g. a collector
+// and a publisher) can share both. If the removed version was pinned, the
+// pin is cleared along with it -- a pin pointing at a version no longer in
+// the table would otherwise permanently block auto-pinning a replacement.
+func (l *loadedPlugins) RemoveByKey(name, typeName string, version int) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i, pl := range *l.table {
+		if pl.Meta.Name == name && pl.Meta.Version == version && pl.Type.String() == typeName {
+			l.splice(i)
+			l.unpinIfRemovedLocked(name, pl.Type, version)
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveByPointer atomically finds and removes lp from the table by
+// identity, returning whether it was found. Used to splice out an entry
+// that never made it to LoadedState, where Meta (and so name/version) may
+// still be zero-valued. Also clears the pin if it was removed while pinned
+// (see RemoveByKey).
+func (l *loadedPlugins) RemoveByPointer(lp *loadedPlugin) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i, pl := range *l.table {
+		if pl == lp {
+			l.splice(i)
+			l.unpinIfRemovedLocked(pl.Meta.Name, pl.Type, pl.Meta.Version)
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveByNameTypeVersion atomically finds and removes the entry matching
+// (name, type, version), returning whether an entry was removed. Unlike
+// pairing ByNameVersion with Splice(index), the find-then-splice here
+// happens under a single lock acquisition, so the index cannot go stale
+// between the two steps. Also clears the pin if it was removed while
+// pinned (see RemoveByKey).
+func (l *loadedPlugins) RemoveByNameTypeVersion(name string, typ plugin.PluginType, version int) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i, pl := range *l.table {
+		if pl.Meta.Name == name && pl.Type == typ && pl.Meta.Version == version {
+			l.splice(i)
+			l.unpinIfRemovedLocked(name, typ, version)
+			return true
+		}
+	}
+	return false
+}
+
 // the struct representing a plugin that is loaded into Pulse
 type loadedPlugin struct {
 	Meta       plugin.PluginMeta
@@ -137,6 +388,72 @@ type loadedPlugin struct {
 	State      pluginState
 	Token      string
 	LoadedTime time.Time
+
+	// ModTime is the binary's mtime as of the load attempt, recorded so a
+	// later filesystem event for the same path can tell "file replaced
+	// since we loaded it" apart from "nothing changed, file just got
+	// touched" -- see Discoverer.changed.
+	ModTime time.Time
+
+	// SignatureFingerprint is the fingerprint of the trusted key that
+	// verified this plugin's signature, or "" if signature verification
+	// was off, warned-and-skipped, or not yet attempted.
+	SignatureFingerprint string
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	err  error
+}
+
+// newLoadedPlugin returns a loadedPlugin in LoadingState, ready to be
+// appended to the table before the backing process has actually started.
+// Its activation Cond is wired up so concurrent lookups can Wait() on it.
+func newLoadedPlugin(path string) *loadedPlugin {
+	lp := &loadedPlugin{Path: path, State: LoadingState}
+	lp.cond = sync.NewCond(&lp.mu)
+	return lp
+}
+
+// Wait blocks while the plugin is in LoadingState and returns once it has
+// settled into LoadedState (nil error) or failed to load (non-nil error,
+// set by markFailed). It is safe to call from multiple goroutines and
+// returns immediately if the plugin has already settled.
+func (lp *loadedPlugin) Wait() error {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	for lp.State == LoadingState {
+		lp.cond.Wait()
+	}
+	return lp.err
+}
+
+// markLoaded transitions the plugin to LoadedState and wakes any waiters.
+func (lp *loadedPlugin) markLoaded() {
+	lp.mu.Lock()
+	lp.State = LoadedState
+	lp.mu.Unlock()
+	lp.cond.Broadcast()
+}
+
+// snapshot returns lp's State and ModTime under lp.mu, for callers that
+// need a consistent read without racing markLoaded/markFailed/loadPlugin's
+// initial ModTime assignment (e.g. Discoverer deciding whether a settled
+// entry's file has changed since it was loaded).
+func (lp *loadedPlugin) snapshot() (state pluginState, modTime time.Time) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.State, lp.ModTime
+}
+
+// markFailed records the error that caused the load to fail and wakes any
+// waiters. The caller is responsible for splicing the entry back out of
+// the table -- a failed plugin never stays cataloged.
+func (lp *loadedPlugin) markFailed(err error) {
+	lp.mu.Lock()
+	lp.State = UnloadedState
+	lp.err = err
+	lp.mu.Unlock()
+	lp.cond.Broadcast()
 }
 
 // returns plugin name
@@ -169,6 +486,46 @@ func (lp *loadedPlugin) LoadedTimestamp() int64 {
 	return lp.LoadedTime.Unix()
 }
 
+// returns the fingerprint of the trusted key that verified this plugin's
+// signature, or "" if it was never signature-verified
+func (lp *loadedPlugin) KeyFingerprint() string {
+	return lp.SignatureFingerprint
+}
+
+// ErrPluginAlreadyLoaded is returned when a plugin claiming the same
+// identity (Name, Type, Version) as one already in the catalog attempts to
+// load. Index is the position of the pre-existing entry in the table.
+type ErrPluginAlreadyLoaded struct {
+	Index   int
+	Name    string
+	Type    plugin.PluginType
+	Version int
+}
+
+func (e ErrPluginAlreadyLoaded) Error() string {
+	return fmt.Sprintf("plugin %s v%d (%s) already loaded at index %d", e.Name, e.Version, e.Type.String(), e.Index)
+}
+
+// ConfirmIdentity checks the table for an entry -- other than lp itself --
+// advertising the same (Name, Type, Version). The pointer check in Append
+// only catches the exact same *loadedPlugin being inserted twice; this
+// catches two different binaries (or the same binary loaded from two
+// paths) that both claim to be the same plugin.
+func (l *loadedPlugins) ConfirmIdentity(lp *loadedPlugin) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i, pl := range *l.table {
+		if pl == lp {
+			continue
+		}
+		if pl.Meta.Name == lp.Meta.Name && pl.Type == lp.Type && pl.Meta.Version == lp.Meta.Version {
+			return ErrPluginAlreadyLoaded{Index: i, Name: lp.Meta.Name, Type: lp.Type, Version: lp.Meta.Version}
+		}
+	}
+	return nil
+}
+
 // the struct representing the object responsible for
 // loading and unloading plugins
 type pluginManager struct {
@@ -176,15 +533,79 @@ type pluginManager struct {
 
 	privKey *rsa.PrivateKey
 	pubKey  *rsa.PublicKey
+
+	// signaturePolicy and trustedKeys drive the signature-verification
+	// pipeline in plugin_signing.go; see RequireSignature* and
+	// AddTrustedKey/RemoveTrustedKey.
+	signaturePolicy signaturePolicy
+	trustedKeysMu   sync.Mutex
+	trustedKeys     map[string]*rsa.PublicKey
+
+	// badPlugins remembers paths that have already been rejected as
+	// duplicates or for a failed signature check so repeated LoadPlugin calls on the same path don't pay
+	// the cost of spawning and handshaking with the plugin process again
+	// just to be rejected a second time. Entries expire after badPluginTTL
+	// so a transient failure (e.g. a signature sidecar that hadn't landed
+	// yet) doesn't blacklist a path forever.
+	badPluginsMu sync.Mutex
+	badPlugins   map[string]badLoadEntry
 }
 
+// badLoadEntry is the remembered outcome of a rejected LoadPlugin attempt.
+type badLoadEntry struct {
+	err   error
+	until time.Time
+}
+
+// badPluginTTL bounds how long a path is short-circuited after being
+// rejected; after it elapses, LoadPlugin re-attempts the load normally.
+const badPluginTTL = 30 * time.Second
+
 func newPluginManager() *pluginManager {
 	p := &pluginManager{
 		LoadedPlugins: newLoadedPlugins(),
+		badPlugins:    make(map[string]badLoadEntry),
 	}
 	return p
 }
 
+// badLoad returns the remembered error for path, if a previous attempt to
+// load it was rejected and badPluginTTL hasn't yet elapsed.
+func (p *pluginManager) badLoad(path string) (error, bool) {
+	p.badPluginsMu.Lock()
+	defer p.badPluginsMu.Unlock()
+
+	entry, bad := p.badPlugins[path]
+	if !bad {
+		return nil, false
+	}
+	if time.Now().After(entry.until) {
+		delete(p.badPlugins, path)
+		return nil, false
+	}
+	return entry.err, true
+}
+
+// markBad remembers that path failed to load with err so future attempts
+// to load it short-circuit without reprocessing the binary, until
+// badPluginTTL elapses.
+func (p *pluginManager) markBad(path string, err error) {
+	p.badPluginsMu.Lock()
+	p.badPlugins[path] = badLoadEntry{err: err, until: time.Now().Add(badPluginTTL)}
+	p.badPluginsMu.Unlock()
+}
+
+// ClearBadPlugin removes path from the bad-plugin set, letting the next
+// LoadPlugin call re-attempt it immediately instead of waiting out
+// badPluginTTL. Useful after resolving the underlying conflict -- e.g.
+// unloading the plugin version that caused a ConfirmIdentity rejection, or
+// dropping a signature sidecar into place.
+func (p *pluginManager) ClearBadPlugin(path string) {
+	p.badPluginsMu.Lock()
+	delete(p.badPlugins, path)
+	p.badPluginsMu.Unlock()
+}
+
 func (p *pluginManager) generateArgs(daemon bool) plugin.Arg {
 	a := plugin.Arg{
 		ControlPubKey: p.pubKey,
@@ -197,22 +618,68 @@ func (p *pluginManager) generateArgs(daemon bool) plugin.Arg {
 // Load is the private method for loading a plugin and
 // saving plugin into the LoadedPlugins array
 func (p *pluginManager) LoadPlugin(path string) error {
+	_, err := p.loadPlugin(path)
+	return err
+}
+
+// loadPlugin is LoadPlugin's implementation, returning the *loadedPlugin it
+// created (or joined) so callers like LoadPluginVersion can inspect the
+// exact entry involved instead of re-deriving it positionally from the
+// table, which is unsafe once other goroutines can load/unload
+// concurrently.
+func (p *pluginManager) loadPlugin(path string) (*loadedPlugin, error) {
 	log.Printf("Attempting to load: %s\v", path)
-	lPlugin := new(loadedPlugin)
-	lPlugin.Path = path
-	lPlugin.State = DetectedState
 
-	ePlugin, err := plugin.NewExecutablePlugin(p.generateArgs(false), lPlugin.Path, false)
+	if err, bad := p.badLoad(path); bad {
+		return nil, err
+	}
 
+	// atomically join an in-flight (or already-settled) entry for this
+	// path, or claim it for ourselves -- FindByPath followed by a separate
+	// Append would leave a gap (signature verification, process spawn)
+	// during which two concurrent callers could both see "not found" and
+	// append duplicate entries for the same path
+	lPlugin, inserted := p.LoadedPlugins.FindOrInsertLoading(path)
+	if !inserted {
+		if err := lPlugin.Wait(); err != nil {
+			return nil, err
+		}
+		return lPlugin, nil
+	}
+
+	// a zero ModTime would make Discoverer.changed treat every future event
+	// as "the file changed" (any real mtime is after the zero value), so a
+	// transient stat failure here falls back to "loaded now" rather than
+	// "loaded at the dawn of time"
+	modTime := time.Now()
+	if stat, statErr := os.Stat(path); statErr == nil {
+		modTime = stat.ModTime()
+	}
+	lPlugin.mu.Lock()
+	lPlugin.ModTime = modTime
+	lPlugin.mu.Unlock()
+
+	fingerprint, err := p.verifySignature(path)
 	if err != nil {
 		log.Println(err)
-		return err
+		p.failLoad(lPlugin, err)
+		p.markBad(path, err)
+		return nil, err
+	}
+	lPlugin.SignatureFingerprint = fingerprint
+
+	ePlugin, err := plugin.NewExecutablePlugin(p.generateArgs(false), lPlugin.Path, false)
+	if err != nil {
+		log.Println(err)
+		p.failLoad(lPlugin, err)
+		return nil, err
 	}
 
 	err = ePlugin.Start()
 	if err != nil {
 		log.Println(err)
-		return err
+		p.failLoad(lPlugin, err)
+		return nil, err
 	}
 
 	var resp *plugin.Response
@@ -220,70 +687,144 @@ func (p *pluginManager) LoadPlugin(path string) error {
 
 	if err != nil {
 		log.Println(err)
-		return err
+		p.failLoad(lPlugin, err)
+		return nil, err
 	}
 
 	if resp.State != plugin.PluginSuccess {
 		log.Println("Plugin loading did not succeed: %s\n", resp.ErrorMessage)
-		return fmt.Errorf("Plugin loading did not succeed: %s\n", resp.ErrorMessage)
+		err := fmt.Errorf("Plugin loading did not succeed: %s\n", resp.ErrorMessage)
+		p.failLoad(lPlugin, err)
+		return nil, err
 	}
 
 	lPlugin.Meta = resp.Meta
 	lPlugin.Type = resp.Type
 	lPlugin.Token = resp.Token
 	lPlugin.LoadedTime = time.Now()
-	lPlugin.State = LoadedState
 
-	err = p.LoadedPlugins.Append(lPlugin)
+	if err := p.LoadedPlugins.ConfirmIdentity(lPlugin); err != nil {
+		log.Println(err)
+		p.failLoad(lPlugin, err)
+		p.markBad(path, err)
+		return nil, err
+	}
+
+	lPlugin.markLoaded()
+
+	// the first version loaded for a (name, type) becomes the pinned
+	// default so existing consumers keep resolving to a concrete version
+	if _, pinned := p.LoadedPlugins.PinnedVersion(lPlugin.Meta.Name, lPlugin.Type); !pinned {
+		p.LoadedPlugins.Pin(lPlugin.Meta.Name, lPlugin.Type, lPlugin.Meta.Version)
+	}
+
+	return lPlugin, nil
+}
+
+// failLoad splices a plugin that never made it to LoadedState back out of
+// the table and wakes any goroutines waiting on it via FindByPath/Get.
+func (p *pluginManager) failLoad(lp *loadedPlugin, err error) {
+	p.LoadedPlugins.RemoveByPointer(lp)
+	lp.markFailed(err)
+}
+
+// LoadPluginVersion loads a plugin the same way LoadPlugin does, but
+// additionally asserts that the version reported by the plugin matches the
+// version the caller expects it to be. This lets operators load a
+// specific version of a plugin binary that may advertise several, and
+// keep multiple versions of the same plugin name/type cataloged at once.
+//
+// version is plugin.PluginMeta's existing integer version, not a parsed
+// semver -- the rest of the catalog (ListVersions, PinPluginVersion, ...)
+// orders and compares on that same integer, so there is no semver
+// ordering anywhere to be consistent with yet. If PluginMeta ever grows a
+// real semantic version, this should take a parsed semver instead of
+// reusing the int.
+func (p *pluginManager) LoadPluginVersion(path string, version int) error {
+	lPlugin, err := p.loadPlugin(path)
 	if err != nil {
 		return err
 	}
 
+	if version != 0 && lPlugin.Meta.Version != version {
+		// the plugin disagrees with the caller about its own version;
+		// unload it rather than leaving a mislabeled entry in the catalog
+		p.LoadedPlugins.RemoveByPointer(lPlugin)
+		return fmt.Errorf("plugin at %s reports version %d, expected %d", path, lPlugin.Meta.Version, version)
+	}
+
 	return nil
 }
 
+// ListVersions returns every version currently cataloged for (name, type).
+func (p *pluginManager) ListVersions(name string, typ plugin.PluginType) []int {
+	return p.LoadedPlugins.Versions(name, typ)
+}
+
+// PinPluginVersion marks version as the one consumers resolve to by
+// default for (name, type).
+func (p *pluginManager) PinPluginVersion(name string, typ plugin.PluginType, version int) error {
+	return p.LoadedPlugins.Pin(name, typ, version)
+}
+
+// UnpinPluginVersion clears the pinned version for (name, type), if any.
+func (p *pluginManager) UnpinPluginVersion(name string, typ plugin.PluginType) {
+	p.LoadedPlugins.Unpin(name, typ)
+}
+
 // unloads a plugin from the LoadedPlugins table
 func (p *pluginManager) UnloadPlugin(pl CatalogedPlugin) error {
+	return p.unloadPlugin(pl.Name(), pl.TypeName(), pl.Version(), false)
+}
 
-	// We hold the mutex here to safely splice out the plugin from the table.
-	// Using a stale index can be slightly dangerous (unloading incorrect plugin).
-	p.LoadedPlugins.Lock()
-	defer p.LoadedPlugins.Unlock()
-
-	var (
-		index  int
-		plugin *loadedPlugin
-		found  bool
-	)
-
-	// find it in the list
-	for p.LoadedPlugins.Next() {
-		if !found {
-			i, lp := p.LoadedPlugins.Item()
-			// plugin key is its name && version
-			if pl.Name() == lp.Meta.Name && pl.Version() == lp.Meta.Version {
-				index = i
-				plugin = lp
-				// use bool for found becase we cannot check against default type values
-				// index of given plugin may be 0
-				found = true
-			}
-		} else {
-			// break out of the loop once we find the plugin we're looking for
-			break
-		}
+// UnloadPluginVersion unloads a specific (name, type, version). Unlike
+// UnloadPlugin, which takes a CatalogedPlugin and matches on its type
+// name, this takes the plugin.PluginType directly -- useful once multiple
+// versions of the same name can be loaded side by side. If the version is
+// pinned, the unload is refused unless force is true (UnloadPlugin never
+// forces).
+func (p *pluginManager) UnloadPluginVersion(name string, typ plugin.PluginType, version int, force bool) error {
+	_, lp, found := p.LoadedPlugins.ByNameVersion(name, typ, version)
+	if !found {
+		return errors.New("plugin [" + name + "] -- [" + strconv.Itoa(version) + "] not found (has it already been unloaded?)")
 	}
 
+	if lp.State != LoadedState {
+		return errors.New("Plugin must be in a LoadedState")
+	}
+
+	if pinned, ok := p.LoadedPlugins.PinnedVersion(name, typ); ok && pinned == version && !force {
+		return fmt.Errorf("plugin [%s] version %d is pinned; unpin it or force the unload", name, version)
+	}
+
+	if !p.LoadedPlugins.RemoveByNameTypeVersion(name, typ, version) {
+		return errors.New("plugin [" + name + "] -- [" + strconv.Itoa(version) + "] was already removed by a concurrent unload")
+	}
+	return nil
+}
+
+// unloadPlugin is the shared implementation behind UnloadPlugin. It takes
+// a typeName (as returned by CatalogedPlugin.TypeName()) alongside
+// name+version, since name+version alone can't disambiguate two plugins of
+// different type that happen to share both (e.g. a collector and a
+// publisher both called "influxdb" v1).
+func (p *pluginManager) unloadPlugin(name, typeName string, version int, force bool) error {
+	lp, found := p.LoadedPlugins.FindByNameTypeName(name, typeName, version)
 	if !found {
-		return errors.New("plugin [" + pl.Name() + "] -- [" + strconv.Itoa(pl.Version()) + "] not found (has it already been unloaded?)")
+		return errors.New("plugin [" + name + "] -- [" + strconv.Itoa(version) + "] not found (has it already been unloaded?)")
 	}
 
-	if plugin.State != LoadedState {
+	if lp.State != LoadedState {
 		return errors.New("Plugin must be in a LoadedState")
 	}
 
-	// splice out the given plugin
-	p.LoadedPlugins.NonblockingSplice(index)
+	if pinned, ok := p.LoadedPlugins.PinnedVersion(lp.Meta.Name, lp.Type); ok && pinned == version && !force {
+		return fmt.Errorf("plugin [%s] version %d is pinned; unpin it or force the unload", name, version)
+	}
+
+	if !p.LoadedPlugins.RemoveByKey(name, typeName, version) {
+		return errors.New("plugin [" + name + "] -- [" + strconv.Itoa(version) + "] was already removed by a concurrent unload")
+	}
 
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,291 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intelsdilabs/pulse/control/plugin"
+)
+
+// TestFindOrInsertLoadingIsAtomic exercises the actual race chunk0-2 set
+// out to close: many goroutines calling FindOrInsertLoading for the same
+// uncatalogued path concurrently must produce exactly one inserted
+// placeholder, with every other caller joining that same entry. A separate
+// FindByPath-then-Append composition would let more than one goroutine
+// observe "not found" and insert its own entry here.
+func TestFindOrInsertLoadingIsAtomic(t *testing.T) {
+	lps := newLoadedPlugins()
+	path := "/tmp/snap-plugin-race"
+
+	const callers = 50
+	results := make([]*loadedPlugin, callers)
+	inserted := make([]bool, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], inserted[i] = lps.FindOrInsertLoading(path)
+		}(i)
+	}
+	wg.Wait()
+
+	insertedCount := 0
+	for i := 0; i < callers; i++ {
+		if inserted[i] {
+			insertedCount++
+		}
+		if results[i] != results[0] {
+			t.Errorf("caller %d got a different entry than caller 0; every caller should join the same placeholder", i)
+		}
+	}
+	if insertedCount != 1 {
+		t.Errorf("expected exactly one caller to insert the placeholder, got %d", insertedCount)
+	}
+	if got := len(lps.Table()); got != 1 {
+		t.Errorf("expected exactly one table entry for the contested path, got %d", got)
+	}
+}
+
+// TestConcurrentLoadPluginJoinsInsteadOfDuplicating exercises the Wait/join
+// half of the same race: once a plugin entry has been claimed and is still
+// LoadingState, every concurrent lookup must share it rather than any
+// caller observing a half-populated entry.
+func TestConcurrentLoadPluginJoinsInsteadOfDuplicating(t *testing.T) {
+	lps := newLoadedPlugins()
+	path := "/tmp/snap-plugin-fake"
+
+	lp := newLoadedPlugin(path)
+	if err := lps.Append(lp); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	const waiters = 5
+	errs := make([]error, waiters)
+	var wg sync.WaitGroup
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			existing, found := lps.FindByPath(path)
+			if !found {
+				errs[i] = errors.New("expected to find the in-flight entry by path")
+				return
+			}
+			errs[i] = existing.Wait()
+			if errs[i] == nil && existing.Meta.Name != "fake" {
+				errs[i] = errors.New("Wait returned before the entry was populated")
+			}
+		}(i)
+	}
+
+	// give the waiters a moment to actually park in Wait() before we
+	// settle the load, so this test would fail without the Cond plumbing
+	time.Sleep(20 * time.Millisecond)
+
+	lp.Meta = plugin.PluginMeta{Name: "fake", Version: 1}
+	lp.Type = plugin.PluginType(0)
+	lp.markLoaded()
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("waiter %d: %v", i, err)
+		}
+	}
+	if got := len(lps.Table()); got != 1 {
+		t.Errorf("expected exactly one table entry after the concurrent loads joined, got %d", got)
+	}
+}
+
+// TestConfirmIdentityRejectsDuplicate covers chunk0-3: two different
+// binaries (or the same binary loaded from two paths) that both claim the
+// same (Name, Type, Version) must be rejected, not silently cataloged
+// twice.
+func TestConfirmIdentityRejectsDuplicate(t *testing.T) {
+	lps := newLoadedPlugins()
+
+	first := newLoadedPlugin("/tmp/snap-plugin-a")
+	first.Meta = plugin.PluginMeta{Name: "influxdb", Version: 1}
+	first.Type = plugin.PluginType(0)
+	if err := lps.Append(first); err != nil {
+		t.Fatalf("Append(first): %v", err)
+	}
+	first.markLoaded()
+
+	second := newLoadedPlugin("/tmp/snap-plugin-b")
+	second.Meta = plugin.PluginMeta{Name: "influxdb", Version: 1}
+	second.Type = plugin.PluginType(0)
+	if err := lps.Append(second); err != nil {
+		t.Fatalf("Append(second): %v", err)
+	}
+
+	err := lps.ConfirmIdentity(second)
+	if err == nil {
+		t.Fatal("expected ConfirmIdentity to reject a second plugin with the same (name, type, version)")
+	}
+
+	already, ok := err.(ErrPluginAlreadyLoaded)
+	if !ok {
+		t.Fatalf("expected ErrPluginAlreadyLoaded, got %T: %v", err, err)
+	}
+	if already.Index != 0 {
+		t.Errorf("expected the pre-existing entry's index (0), got %d", already.Index)
+	}
+}
+
+// TestRangeSafeDuringConcurrentMutation covers chunk0-5: Range iterates a
+// snapshot taken under the lock, so it must be safe to call while another
+// goroutine is concurrently removing entries from the live table.
+func TestRangeSafeDuringConcurrentMutation(t *testing.T) {
+	lps := newLoadedPlugins()
+	const n = 50
+	for i := 0; i < n; i++ {
+		lp := newLoadedPlugin(fmt.Sprintf("/tmp/snap-plugin-%d", i))
+		lp.Meta = plugin.PluginMeta{Name: fmt.Sprintf("plugin%d", i), Version: 1}
+		lp.Type = plugin.PluginType(0)
+		if err := lps.Append(lp); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		lp.markLoaded()
+	}
+	typeName := lps.Table()[0].Type.String()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		i := 0
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			lps.RemoveByKey(fmt.Sprintf("plugin%d", i%n), typeName, 1)
+			i++
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		lps.Range(func(_ int, lp *loadedPlugin) bool {
+			// touching lp here would race if Range iterated the live
+			// table instead of a snapshot
+			_ = lp.Meta.Name
+			return true
+		})
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+// TestUnloadPluginVersionRespectsPinUntilForced covers chunk0-1's pinning
+// contract as exercised through chunk0-5's rewritten UnloadPluginVersion:
+// a pinned version refuses to unload until the caller forces it, and the
+// pin itself is cleared along with the version it pointed at -- otherwise
+// a stale pin would block any replacement version from being auto-pinned.
+func TestUnloadPluginVersionRespectsPinUntilForced(t *testing.T) {
+	pm := newPluginManager()
+
+	lp := newLoadedPlugin("/tmp/snap-plugin-pinned")
+	lp.Meta = plugin.PluginMeta{Name: "pinned", Version: 1}
+	lp.Type = plugin.PluginType(0)
+	if err := pm.LoadedPlugins.Append(lp); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	lp.markLoaded()
+
+	if err := pm.PinPluginVersion("pinned", lp.Type, 1); err != nil {
+		t.Fatalf("PinPluginVersion: %v", err)
+	}
+
+	if err := pm.UnloadPluginVersion("pinned", lp.Type, 1, false); err == nil {
+		t.Fatal("expected unloading a pinned version to be refused without force")
+	}
+
+	if err := pm.UnloadPluginVersion("pinned", lp.Type, 1, true); err != nil {
+		t.Fatalf("expected a forced unload of a pinned version to succeed, got: %v", err)
+	}
+
+	if _, _, found := pm.LoadedPlugins.ByNameVersion("pinned", lp.Type, 1); found {
+		t.Error("expected the plugin to be removed from the table after the forced unload")
+	}
+
+	if pinned, ok := pm.LoadedPlugins.PinnedVersion("pinned", lp.Type); ok {
+		t.Errorf("expected the pin to be cleared along with the removed version, still pinned to %d", pinned)
+	}
+}
+
+// TestRemovingPinnedVersionClearsPinAcrossRemovalPaths covers the same gap
+// as TestUnloadPluginVersionRespectsPinUntilForced, but directly against
+// the three removal primitives UnloadPlugin/UnloadPluginVersion/LoadPluginVersion
+// ultimately funnel through, so a future caller of any of them inherits
+// the same guarantee.
+func TestRemovingPinnedVersionClearsPinAcrossRemovalPaths(t *testing.T) {
+	newPinned := func(path, name string) *loadedPlugin {
+		lp := newLoadedPlugin(path)
+		lp.Meta = plugin.PluginMeta{Name: name, Version: 1}
+		lp.Type = plugin.PluginType(0)
+		return lp
+	}
+
+	t.Run("RemoveByKey", func(t *testing.T) {
+		lps := newLoadedPlugins()
+		lp := newPinned("/tmp/snap-plugin-by-key", "by-key")
+		if err := lps.Append(lp); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		lp.markLoaded()
+		if err := lps.Pin("by-key", lp.Type, 1); err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+		if !lps.RemoveByKey("by-key", lp.Type.String(), 1) {
+			t.Fatal("expected RemoveByKey to find and remove the entry")
+		}
+		if _, ok := lps.PinnedVersion("by-key", lp.Type); ok {
+			t.Error("expected RemoveByKey to clear the pin along with the removed version")
+		}
+	})
+
+	t.Run("RemoveByNameTypeVersion", func(t *testing.T) {
+		lps := newLoadedPlugins()
+		lp := newPinned("/tmp/snap-plugin-by-ntv", "by-ntv")
+		if err := lps.Append(lp); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		lp.markLoaded()
+		if err := lps.Pin("by-ntv", lp.Type, 1); err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+		if !lps.RemoveByNameTypeVersion("by-ntv", lp.Type, 1) {
+			t.Fatal("expected RemoveByNameTypeVersion to find and remove the entry")
+		}
+		if _, ok := lps.PinnedVersion("by-ntv", lp.Type); ok {
+			t.Error("expected RemoveByNameTypeVersion to clear the pin along with the removed version")
+		}
+	})
+
+	t.Run("RemoveByPointer", func(t *testing.T) {
+		lps := newLoadedPlugins()
+		lp := newPinned("/tmp/snap-plugin-by-ptr", "by-ptr")
+		if err := lps.Append(lp); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		lp.markLoaded()
+		if err := lps.Pin("by-ptr", lp.Type, 1); err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+		if !lps.RemoveByPointer(lp) {
+			t.Fatal("expected RemoveByPointer to find and remove the entry")
+		}
+		if _, ok := lps.PinnedVersion("by-ptr", lp.Type); ok {
+			t.Error("expected RemoveByPointer to clear the pin along with the removed version")
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package control
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+)
+
+// signaturePolicy controls how pluginManager.LoadPlugin reacts when a
+// plugin's signature is missing or fails to verify against a trusted key.
+type signaturePolicy string
+
+const (
+	// RequireSignatureOff skips signature verification entirely.
+	RequireSignatureOff signaturePolicy = "off"
+	// RequireSignatureWarn verifies when a signature is present but only
+	// logs on failure or absence -- the plugin still loads.
+	RequireSignatureWarn signaturePolicy = "warn"
+	// RequireSignatureEnforce refuses to load a plugin whose signature is
+	// missing or does not verify against a trusted key.
+	RequireSignatureEnforce signaturePolicy = "enforce"
+)
+
+// keyFingerprint returns a stable, comparable identifier for an RSA public
+// key: the hex-encoded SHA-256 of its PKIX encoding.
+func keyFingerprint(pub *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AddTrustedKey registers pub as a key LoadPlugin will accept signatures
+// from, and returns its fingerprint.
+func (p *pluginManager) AddTrustedKey(pub *rsa.PublicKey) (string, error) {
+	fp, err := keyFingerprint(pub)
+	if err != nil {
+		return "", err
+	}
+
+	p.trustedKeysMu.Lock()
+	defer p.trustedKeysMu.Unlock()
+	if p.trustedKeys == nil {
+		p.trustedKeys = make(map[string]*rsa.PublicKey)
+	}
+	p.trustedKeys[fp] = pub
+
+	return fp, nil
+}
+
+// RemoveTrustedKey revokes trust in the key with the given fingerprint.
+// Plugins already loaded under that key are unaffected.
+func (p *pluginManager) RemoveTrustedKey(fingerprint string) {
+	p.trustedKeysMu.Lock()
+	defer p.trustedKeysMu.Unlock()
+	delete(p.trustedKeys, fingerprint)
+}
+
+// SetRequireSignature sets the policy LoadPlugin enforces on plugin
+// signatures going forward.
+func (p *pluginManager) SetRequireSignature(policy signaturePolicy) {
+	p.signaturePolicy = policy
+}
+
+// verifySignature checks path against a sidecar "<path>.sig" file produced
+// by SignPluginFile. It returns the fingerprint of the trusted key that
+// verified the signature, or an empty string if no signature was checked
+// (policy is off, or policy is warn and no signature matched). A non-nil
+// error means LoadPlugin should refuse to load the plugin.
+func (p *pluginManager) verifySignature(path string) (string, error) {
+	if p.signaturePolicy == "" || p.signaturePolicy == RequireSignatureOff {
+		return "", nil
+	}
+
+	sigBytes, err := ioutil.ReadFile(path + ".sig")
+	if err != nil {
+		if p.signaturePolicy == RequireSignatureEnforce {
+			return "", fmt.Errorf("signature required but missing for %s: %v", path, err)
+		}
+		log.Printf("warning: no signature found for %s", path)
+		return "", nil
+	}
+
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	hashed := sha256.Sum256(fileBytes)
+
+	p.trustedKeysMu.Lock()
+	defer p.trustedKeysMu.Unlock()
+	for fp, pub := range p.trustedKeys {
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], sigBytes) == nil {
+			return fp, nil
+		}
+	}
+
+	if p.signaturePolicy == RequireSignatureEnforce {
+		return "", fmt.Errorf("signature verification failed for %s: no trusted key matched", path)
+	}
+	log.Printf("warning: signature verification failed for %s; no trusted key matched", path)
+	return "", nil
+}
+
+// SignPluginFile computes the SHA-256 of the plugin binary at path, signs
+// it with priv, and writes the signature alongside it as "<path>.sig" for
+// LoadPlugin to verify later. The snap-plugin-sign command wraps it.
+func SignPluginFile(path string, priv *rsa.PrivateKey) error {
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256(fileBytes)
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, hashed[:])
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path+".sig", sig, 0644)
+}